@@ -1,15 +1,14 @@
 package jwt
 
 import (
+	"context"
 	"crypto/rsa"
 	"crypto/subtle"
 	"encoding/base64"
 	"encoding/binary"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"math"
 	"math/big"
 	"net/http"
 	"strings"
@@ -43,6 +42,20 @@ const (
 
 	// IssuerFieldName the issuer field name
 	IssuerFieldName = "iss"
+
+	// JWTTokenKey is the gin context key the validated *jwtgo.Token is
+	// stored under by both AuthMiddleware and MultiIssuerMiddleware.
+	JWTTokenKey = "JWT_TOKEN"
+
+	// UnauthorizedHandlerKey is the gin context key the owning middleware's
+	// Unauthorized hook is stored under by both AuthMiddleware and
+	// MultiIssuerMiddleware, so guards like RequireScopes can route their
+	// 403s through the same hook the middleware uses for its own 401s.
+	UnauthorizedHandlerKey = "JWT_UNAUTHORIZED_HANDLER"
+
+	// DefaultLeewaySeconds is the clock skew tolerance applied to exp, nbf
+	// and iat checks when LeewaySeconds isn't set, per RFC 7519 §4.1.
+	DefaultLeewaySeconds = 60
 )
 
 // AuthMiddleware middleware
@@ -52,12 +65,24 @@ type AuthMiddleware struct {
 
 	Timeout time.Duration
 
-	// TokenLookup the header name of the token
+	// TokenLookup is a comma-separated list of "source:key" pairs tried in
+	// order, e.g. "header:Authorization,cookie:jwt,query:access_token".
+	// Supported sources are header, cookie, query and form. Defaults to
+	// "header:Authorization".
 	TokenLookup string
 
+	// AuthScheme is the scheme stripped from the header token source (e.g.
+	// "Bearer" from "Bearer <token>"), case-insensitively. It does not
+	// apply to the cookie, query or form sources. Defaults to "Bearer".
+	AuthScheme string
+
 	// TimeFunc
 	TimeFunc func() time.Time
 
+	// LeewaySeconds is the clock skew tolerance applied to exp, nbf and iat
+	// checks. Defaults to DefaultLeewaySeconds.
+	LeewaySeconds int64
+
 	// Realm name to display to the user. Required.
 	Realm string
 
@@ -73,8 +98,15 @@ type AuthMiddleware struct {
 	// The issuer
 	Iss string
 
-	// JWK public JSON Web Key (JWK) for your user pool
+	// JWK public JSON Web Key (JWK) for your user pool. Set this directly
+	// for a static, non-rotating key set (e.g. in tests). AuthJWTMiddleware
+	// populates JWKS instead, which takes precedence when both are set.
 	JWK map[string]JWKKey
+
+	// JWKS is a background-refreshed JWKS cache. When set, it is used
+	// instead of JWK (JWK is not consulted), and a kid miss triggers an out-of-band
+	// refresh in case Cognito rotated its signing keys.
+	JWKS *JWKSCache
 }
 
 // JWK is json data struct for JSON Web Key
@@ -104,6 +136,10 @@ func (mw *AuthMiddleware) MiddlewareInit() {
 		mw.TokenLookup = "header:" + HeaderAuthorization
 	}
 
+	if mw.AuthScheme == "" {
+		mw.AuthScheme = "Bearer"
+	}
+
 	if mw.Timeout == 0 {
 		mw.Timeout = time.Hour
 	}
@@ -112,6 +148,10 @@ func (mw *AuthMiddleware) MiddlewareInit() {
 		mw.TimeFunc = time.Now
 	}
 
+	if mw.LeewaySeconds == 0 {
+		mw.LeewaySeconds = DefaultLeewaySeconds
+	}
+
 	if mw.Unauthorized == nil {
 		mw.Unauthorized = func(c *gin.Context, code int, message string) {
 			c.JSON(code, AuthError{Code: code, Message: message})
@@ -125,15 +165,7 @@ func (mw *AuthMiddleware) MiddlewareInit() {
 
 func (mw *AuthMiddleware) middlewareImpl(c *gin.Context) {
 	// Parse the given token
-	var tokenStr string
-	var err error
-
-	parts := strings.Split(mw.TokenLookup, ":")
-	switch parts[0] {
-	case HEADER:
-		tokenStr, err = mw.jwtFromHeader(c, parts[1])
-	}
-
+	tokenStr, err := tokenFromRequest(c, mw.TokenLookup, mw.AuthScheme)
 	if err != nil {
 		log.Printf("JWT token Parser error: %s", err.Error())
 		mw.unauthorized(c, http.StatusUnauthorized, err.Error())
@@ -147,19 +179,11 @@ func (mw *AuthMiddleware) middlewareImpl(c *gin.Context) {
 		return
 	}
 
-	c.Set("JWT_TOKEN", token)
+	c.Set(JWTTokenKey, token)
+	c.Set(UnauthorizedHandlerKey, mw.Unauthorized)
 	c.Next()
 }
 
-func (mw *AuthMiddleware) jwtFromHeader(c *gin.Context, key string) (string, error) {
-	authHeader := c.Request.Header.Get(key)
-
-	if authHeader == "" {
-		return "", ErrAuthHeaderEmpty
-	}
-	return authHeader, nil
-}
-
 func (mw *AuthMiddleware) unauthorized(c *gin.Context, code int, message string) {
 	if mw.Realm == "" {
 		mw.Realm = "gin jwt"
@@ -179,18 +203,28 @@ func (mw *AuthMiddleware) MiddlewareFunc() gin.HandlerFunc {
 	}
 }
 
-// AuthJWTMiddleware create an instance of the middle ware function
-func AuthJWTMiddleware(iss, userPoolID, region string) (*AuthMiddleware, error) {
-	// Download the public json web key for the given user pool ID at the start of the plugin
-	jwk, err := getJWK(fmt.Sprintf("https://cognito-idp.%v.amazonaws.com/%v/.well-known/jwks.json",
+// AuthJWTMiddleware create an instance of the middle ware function. It
+// fetches the public JSON web keys for the given user pool ID and starts a
+// JWKSCache that keeps them fresh in the background; pass JWKSCacheOption
+// values (e.g. WithRefreshInterval) to tune that behaviour.
+func AuthJWTMiddleware(iss, userPoolID, region string, opts ...JWKSCacheOption) (*AuthMiddleware, error) {
+	jwksURL := fmt.Sprintf("https://cognito-idp.%v.amazonaws.com/%v/.well-known/jwks.json",
 		region,
 		userPoolID,
-	))
+	)
+	jwks, err := NewJWKSCache(jwksURL, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	authMiddleware := &AuthMiddleware{
+	return newAuthMiddlewareWithCache(iss, userPoolID, region, jwks), nil
+}
+
+// newAuthMiddlewareWithCache builds an AuthMiddleware with its defaults
+// filled in around an already-constructed JWKSCache. It underlies
+// AuthJWTMiddleware and the offline constructors in offline.go.
+func newAuthMiddlewareWithCache(iss, userPoolID, region string, jwks *JWKSCache) *AuthMiddleware {
+	return &AuthMiddleware{
 		Timeout: time.Hour,
 
 		Unauthorized: func(c *gin.Context, code int, message string) {
@@ -198,14 +232,15 @@ func AuthJWTMiddleware(iss, userPoolID, region string) (*AuthMiddleware, error)
 		},
 
 		// Token header
-		TokenLookup: "header:" + HeaderAuthorization,
-		TimeFunc:    time.Now,
-		JWK:         jwk,
-		Iss:         iss,
-		Region:      region,
-		UserPoolID:  userPoolID,
-	}
-	return authMiddleware, nil
+		TokenLookup:   "header:" + HeaderAuthorization,
+		AuthScheme:    "Bearer",
+		TimeFunc:      time.Now,
+		LeewaySeconds: DefaultLeewaySeconds,
+		JWKS:          jwks,
+		Iss:           iss,
+		Region:        region,
+		UserPoolID:    userPoolID,
+	}
 }
 
 func (mw *AuthMiddleware) parse(tokenStr string) (*jwtgo.Token, error) {
@@ -217,17 +252,18 @@ func (mw *AuthMiddleware) parse(tokenStr string) (*jwtgo.Token, error) {
 		}
 
 		// 5. Get the kid from the JWT token header and retrieve the corresponding JSON Web Key that was stored
-		if kid, ok := token.Header["kid"]; ok {
-			if kidStr, ok := kid.(string); ok {
-				key := mw.JWK[kidStr]
-				// 6. Verify the signature of the decoded JWT token.
-				rsaPublicKey := convertKey(key.E, key.N)
-				return rsaPublicKey, nil
-			}
+		kidStr, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("token header does not contain a kid")
 		}
 
-		// rsa public key
-		return "", nil
+		key, ok := mw.lookupKey(kidStr)
+		if !ok {
+			return nil, fmt.Errorf("no matching jwk found for kid %q", kidStr)
+		}
+
+		// 6. Verify the signature of the decoded JWT token.
+		return convertKey(key.E, key.N), nil
 	})
 	if err != nil {
 		return token, err
@@ -235,16 +271,11 @@ func (mw *AuthMiddleware) parse(tokenStr string) (*jwtgo.Token, error) {
 
 	claims := token.Claims.(jwtgo.MapClaims)
 
-	iss, ok := claims["iss"]
-	if !ok {
+	if _, ok := claims["iss"]; !ok {
 		return token, fmt.Errorf("token does not contain issuer")
 	}
-	issStr := iss.(string)
-	if strings.Contains(issStr, "cognito-idp") {
-		err = validateAWSJwtClaims(claims, mw.Region, mw.UserPoolID)
-		if err != nil {
-			return token, err
-		}
+	if err := mw.validateJwtClaims(claims); err != nil {
+		return token, err
 	}
 
 	if token.Valid {
@@ -253,43 +284,78 @@ func (mw *AuthMiddleware) parse(tokenStr string) (*jwtgo.Token, error) {
 	return token, err
 }
 
-// validateAWSJwtClaims validates AWS Cognito User Pool JWT
-func validateAWSJwtClaims(claims jwtgo.MapClaims, region, userPoolID string) error {
-	var err error
-	// 3. Check the iss claim. It should match your user pool.
-	issShoudBe := fmt.Sprintf("https://cognito-idp.%v.amazonaws.com/%v", region, userPoolID)
-	err = validateClaimItem("iss", []string{issShoudBe}, claims)
-	if err != nil {
-		Error.Printf("Failed to validate the jwt token claims %v", err)
-		return err
-	}
-
-	// 4. Check the token_use claim.
-	validateTokenUse := func() error {
-		if tokenUse, ok := claims["token_use"]; ok {
-			if tokenUseStr, ok := tokenUse.(string); ok {
-				if tokenUseStr == "id" || tokenUseStr == "access" {
-					return nil
-				}
-			}
+// lookupKey resolves a kid to a JWK. It prefers the background-refreshed
+// JWKS cache when one is configured, falling back to the static JWK map set
+// directly on the middleware (e.g. in tests). A kid miss against the cache
+// triggers an out-of-band refresh in case Cognito rotated its signing keys
+// since the last scheduled fetch.
+func (mw *AuthMiddleware) lookupKey(kid string) (JWKKey, bool) {
+	if mw.JWKS != nil {
+		if key, ok := mw.JWKS.Get(kid); ok {
+			return key, true
 		}
-		return errors.New("token_use should be id or access")
+		mw.JWKS.TriggerRefreshForUnknownKid(context.Background())
+		return mw.JWKS.Get(kid)
 	}
 
-	err = validateTokenUse()
-	if err != nil {
-		return err
-	}
+	key, ok := mw.JWK[kid]
+	return key, ok
+}
 
-	// 7. Check the exp claim and make sure the token is not expired.
-	err = validateExpired(claims)
-	if err != nil {
-		return err
+// claimsValidator validates a single aspect of a parsed token's claims.
+type claimsValidator func(claims jwtgo.MapClaims) error
+
+// validateJwtClaims runs the validators that apply to a token issued by
+// mw.Iss: issuer, exp, nbf and iat apply to any provider; token_use is a
+// Cognito-specific claim and is only enforced when mw.Iss looks like a
+// Cognito user pool issuer, so this also validates tokens from other OIDC
+// providers federated via MultiIssuerMiddleware. All time-based checks
+// read "now" through mw.TimeFunc, so tests can control the clock, and
+// tolerate mw.LeewaySeconds of clock skew either side per RFC 7519 §4.1.
+func (mw *AuthMiddleware) validateJwtClaims(claims jwtgo.MapClaims) error {
+	now := mw.TimeFunc()
+	leeway := time.Duration(mw.LeewaySeconds) * time.Second
+
+	chain := []claimsValidator{
+		func(claims jwtgo.MapClaims) error {
+			return validateClaimItem(IssuerFieldName, []string{mw.Iss}, claims)
+		},
+		func(claims jwtgo.MapClaims) error {
+			return validateExpired(claims, now, leeway)
+		},
+		func(claims jwtgo.MapClaims) error {
+			return validateNotBefore(claims, now, leeway)
+		},
+		func(claims jwtgo.MapClaims) error {
+			return validateIssuedAt(claims, now, leeway)
+		},
+	}
+	if strings.Contains(mw.Iss, "cognito-idp") {
+		chain = append(chain, validateCognitoTokenUse)
 	}
 
+	for _, validate := range chain {
+		if err := validate(claims); err != nil {
+			Error.Printf("Failed to validate the jwt token claims %v", err)
+			return err
+		}
+	}
 	return nil
 }
 
+// validateCognitoTokenUse checks the token_use claim Cognito sets on both
+// its id and access tokens.
+func validateCognitoTokenUse(claims jwtgo.MapClaims) error {
+	if tokenUse, ok := claims["token_use"]; ok {
+		if tokenUseStr, ok := tokenUse.(string); ok {
+			if tokenUseStr == "id" || tokenUseStr == "access" {
+				return nil
+			}
+		}
+	}
+	return errors.New("token_use should be id or access")
+}
+
 var ErrInvalidClaim = errors.New("invalid claim")
 
 func validateClaimItem(key string, keyShouldBe []string, claims jwtgo.MapClaims) error {
@@ -311,26 +377,67 @@ func validateClaimItem(key string, keyShouldBe []string, claims jwtgo.MapClaims)
 }
 
 var (
-	ErrExpiredToken = errors.New("expired token")
-	ErrParseToken   = errors.New("cannot parse token exp")
+	ErrExpiredToken        = errors.New("expired token")
+	ErrParseToken          = errors.New("cannot parse token exp")
+	ErrTokenNotYetValid    = errors.New("token is not valid yet")
+	ErrTokenIssuedInFuture = errors.New("token iat is too far in the future")
 )
 
-func validateExpired(claims jwtgo.MapClaims) error {
-	if tokenExp, ok := claims["exp"]; ok {
-		if exp, ok := tokenExp.(float64); ok {
-			now := int(time.Now().Unix())
-			// Convert user input to a natural number since behavior of
-			// subtle.ConstantTimeLessOrEq() is undefined with negative numbers
-			absExp := int(math.Abs(exp))
-			// This function is prone to year 2038 problem but at least
-			// it's protecting against timing attacks
-			if subtle.ConstantTimeLessOrEq(now, absExp) == 1 {
-				return nil
-			}
-			return ErrExpiredToken
-		}
+// validateExpired rejects a token whose exp has passed, tolerating leeway
+// of clock skew. A missing or non-numeric exp is rejected outright; a
+// negative exp is just a point in time before the epoch and is rejected by
+// the same now-after-exp+leeway comparison, no special-casing needed.
+func validateExpired(claims jwtgo.MapClaims, now time.Time, leeway time.Duration) error {
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return ErrParseToken
 	}
-	return ErrParseToken
+	if now.After(exp.Add(leeway)) {
+		return ErrExpiredToken
+	}
+	return nil
+}
+
+// validateNotBefore rejects a token presented before its nbf, tolerating
+// leeway of clock skew. nbf is optional per RFC 7519 §4.1.5; a token
+// without one is accepted.
+func validateNotBefore(claims jwtgo.MapClaims, now time.Time, leeway time.Duration) error {
+	nbf, ok := numericClaim(claims, "nbf")
+	if !ok {
+		return nil
+	}
+	if now.Before(nbf.Add(-leeway)) {
+		return ErrTokenNotYetValid
+	}
+	return nil
+}
+
+// validateIssuedAt rejects a token whose iat is more than leeway in the
+// future, the same sanity check go-ethereum's engine-API JWT handler uses
+// to bound replay windows. iat is optional; a token without one is
+// accepted.
+func validateIssuedAt(claims jwtgo.MapClaims, now time.Time, leeway time.Duration) error {
+	iat, ok := numericClaim(claims, "iat")
+	if !ok {
+		return nil
+	}
+	if iat.After(now.Add(leeway)) {
+		return ErrTokenIssuedInFuture
+	}
+	return nil
+}
+
+// numericClaim reads a Unix-timestamp claim (exp, nbf, iat) as a time.Time.
+func numericClaim(claims jwtgo.MapClaims, key string) (time.Time, bool) {
+	val, ok := claims[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, ok := val.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
 }
 
 func convertKey(rawE, rawN string) *rsa.PublicKey {
@@ -354,25 +461,3 @@ func convertKey(rawE, rawN string) *rsa.PublicKey {
 	pubKey.N.SetBytes(decodedN)
 	return pubKey
 }
-
-// Download the json web public key for the given user pool id
-func getJWK(jwkURL string) (map[string]JWKKey, error) {
-	Info.Printf("Downloading the jwk from the given url %s", jwkURL)
-	jwk := &JWK{}
-
-	myClient := &http.Client{Timeout: 10 * time.Second}
-	r, err := myClient.Get(jwkURL)
-	if err != nil {
-		return nil, err
-	}
-	defer r.Body.Close()
-	if err := json.NewDecoder(r.Body).Decode(jwk); err != nil {
-		return nil, err
-	}
-
-	jwkMap := make(map[string]JWKKey, 0)
-	for _, jwk := range jwk.Keys {
-		jwkMap[jwk.Kid] = jwk
-	}
-	return jwkMap, nil
-}
@@ -8,7 +8,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	jwtgo "github.com/golang-jwt/jwt"
+	jwtgo "github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,10 +22,10 @@ func Test_MissingAuthorizationHeader(t *testing.T) {
 	t.Logf("Given the authorization header is not set")
 	{
 		middleware := AuthMiddleware{UserPoolID: "some_user_id_pool", Region: "some_region"}
-		emptyMap := http.Header{}
-		request := http.Request{Header: emptyMap}
-		ctx := gin.Context{Request: &request}
-		_, err := middleware.jwtFromHeader(&ctx, AuthorizationHeader)
+		middleware.MiddlewareInit()
+
+		ctx := newTestContext(http.MethodGet, "/", "", nil)
+		_, err := tokenFromRequest(ctx, middleware.TokenLookup, middleware.AuthScheme)
 		assert.NotNil(t, err)
 		assert.Equal(t, "auth header empty", err.Error())
 		expectedErrorMessage := "auth header empty"
@@ -58,7 +58,7 @@ func Test_CognitoTokenExpiredShouldResultInUnauthorisedError(t *testing.T) {
 
 func performRequest(r http.Handler, method, path string, token string) *httptest.ResponseRecorder {
 	headers := http.Header{}
-	headers.Add(AuthorizationHeader, token)
+	headers.Add(HeaderAuthorization, token)
 	req, _ := http.NewRequest(method, path, nil)
 	req.Header = headers
 
@@ -155,6 +155,24 @@ func Test_validateExpired(t *testing.T) {
 			},
 			expectedErr: ErrExpiredToken,
 		},
+		{
+			name: "Negative exp is simply expired",
+			args: args{
+				claims: map[string]interface{}{
+					"exp": float64(-1000),
+				},
+			},
+			expectedErr: ErrExpiredToken,
+		},
+		{
+			name: "Expired token within leeway",
+			args: args{
+				claims: map[string]interface{}{
+					"exp": float64(time.Now().Unix() - 30),
+				},
+			},
+			expectedErr: nil,
+		},
 		{
 			name: "Token not found",
 			args: args{
@@ -165,10 +183,96 @@ func Test_validateExpired(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateExpired(tt.args.claims)
+			err := validateExpired(tt.args.claims, time.Now(), 60*time.Second)
 			if err != nil && !errors.Is(err, tt.expectedErr) {
 				t.Errorf("validateExpired() got error = %v, expected = %v", err, tt.expectedErr)
 			}
 		})
 	}
 }
+
+func Test_validateNotBefore(t *testing.T) {
+	tests := []struct {
+		claims      jwtgo.MapClaims
+		expectedErr error
+		name        string
+	}{
+		{
+			name:        "No nbf claim is valid",
+			claims:      map[string]interface{}{},
+			expectedErr: nil,
+		},
+		{
+			name: "nbf in the past is valid",
+			claims: map[string]interface{}{
+				"nbf": float64(time.Now().Unix() - 1000),
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "nbf in the future is rejected",
+			claims: map[string]interface{}{
+				"nbf": float64(time.Now().Unix() + 1000),
+			},
+			expectedErr: ErrTokenNotYetValid,
+		},
+		{
+			name: "nbf just ahead is tolerated within leeway",
+			claims: map[string]interface{}{
+				"nbf": float64(time.Now().Unix() + 30),
+			},
+			expectedErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNotBefore(tt.claims, time.Now(), 60*time.Second)
+			if err != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("validateNotBefore() got error = %v, expected = %v", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func Test_validateIssuedAt(t *testing.T) {
+	tests := []struct {
+		claims      jwtgo.MapClaims
+		expectedErr error
+		name        string
+	}{
+		{
+			name:        "No iat claim is valid",
+			claims:      map[string]interface{}{},
+			expectedErr: nil,
+		},
+		{
+			name: "iat in the past is valid",
+			claims: map[string]interface{}{
+				"iat": float64(time.Now().Unix() - 1000),
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "iat far in the future is rejected",
+			claims: map[string]interface{}{
+				"iat": float64(time.Now().Unix() + 1000),
+			},
+			expectedErr: ErrTokenIssuedInFuture,
+		},
+		{
+			name: "iat just ahead is tolerated within leeway",
+			claims: map[string]interface{}{
+				"iat": float64(time.Now().Unix() + 30),
+			},
+			expectedErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIssuedAt(tt.claims, time.Now(), 60*time.Second)
+			if err != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("validateIssuedAt() got error = %v, expected = %v", err, tt.expectedErr)
+			}
+		})
+	}
+}
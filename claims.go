@@ -0,0 +1,188 @@
+package jwt
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt/v4"
+)
+
+// Claims is the typed view over a validated token's claims returned by
+// ExtractClaims.
+type Claims = CognitoClaims
+
+// CognitoClaims is a typed view over the claims Cognito puts on its id and
+// access tokens. Raw holds the underlying claims so callers can still reach
+// fields this struct doesn't surface.
+type CognitoClaims struct {
+	Subject  string
+	Username string
+	Email    string
+	Groups   []string
+	Scope    string
+	ClientID string
+	TokenUse string
+	AuthTime time.Time
+
+	Raw jwtgo.MapClaims
+}
+
+// Scopes splits the space-delimited scope claim into individual scopes.
+func (c CognitoClaims) Scopes() []string {
+	if c.Scope == "" {
+		return nil
+	}
+	return strings.Fields(c.Scope)
+}
+
+// HasScope reports whether scope is present in the token's scope claim.
+func (c CognitoClaims) HasScope(scope string) bool {
+	for _, s := range c.Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasGroup reports whether group is present in the token's cognito:groups
+// claim.
+func (c CognitoClaims) HasGroup(group string) bool {
+	for _, g := range c.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractClaims returns the typed claims of the token validated earlier in
+// the chain by AuthMiddleware or MultiIssuerMiddleware. It returns false if
+// no validated token is present on the context.
+func ExtractClaims(c *gin.Context) (Claims, bool) {
+	tokenVal, ok := c.Get(JWTTokenKey)
+	if !ok {
+		return Claims{}, false
+	}
+
+	token, ok := tokenVal.(*jwtgo.Token)
+	if !ok {
+		return Claims{}, false
+	}
+
+	mapClaims, ok := token.Claims.(jwtgo.MapClaims)
+	if !ok {
+		return Claims{}, false
+	}
+
+	return newCognitoClaims(mapClaims), true
+}
+
+func newCognitoClaims(claims jwtgo.MapClaims) CognitoClaims {
+	c := CognitoClaims{Raw: claims}
+
+	if v, ok := claims["sub"].(string); ok {
+		c.Subject = v
+	}
+	if v, ok := claims["username"].(string); ok {
+		c.Username = v
+	} else if v, ok := claims["cognito:username"].(string); ok {
+		c.Username = v
+	}
+	if v, ok := claims["email"].(string); ok {
+		c.Email = v
+	}
+	if groups, ok := claims["cognito:groups"].([]interface{}); ok {
+		c.Groups = make([]string, 0, len(groups))
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				c.Groups = append(c.Groups, s)
+			}
+		}
+	}
+	if v, ok := claims["scope"].(string); ok {
+		c.Scope = v
+	}
+	if v, ok := claims["client_id"].(string); ok {
+		c.ClientID = v
+	}
+	if v, ok := claims["token_use"].(string); ok {
+		c.TokenUse = v
+	}
+	if v, ok := claims["auth_time"].(float64); ok {
+		c.AuthTime = time.Unix(int64(v), 0)
+	}
+
+	return c
+}
+
+// RequireScopes returns a gin middleware that 403s via forbidden unless the
+// validated token carries every scope listed. Mount it after
+// AuthMiddleware/MultiIssuerMiddleware.
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ExtractClaims(c)
+		if !ok {
+			forbidden(c, "no validated claims found for request")
+			return
+		}
+		for _, scope := range scopes {
+			if !claims.HasScope(scope) {
+				forbidden(c, "missing required scope "+strings.TrimSpace(scope))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireGroups returns a gin middleware that 403s unless the validated
+// token's cognito:groups claim contains every group listed.
+func RequireGroups(groups ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ExtractClaims(c)
+		if !ok {
+			forbidden(c, "no validated claims found for request")
+			return
+		}
+		for _, group := range groups {
+			if !claims.HasGroup(group) {
+				forbidden(c, "missing required group "+strings.TrimSpace(group))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireTokenUse returns a gin middleware that 403s unless the validated
+// token's token_use claim equals use (e.g. "access" or "id").
+func RequireTokenUse(use string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ExtractClaims(c)
+		if !ok || claims.TokenUse != use {
+			forbidden(c, "token_use should be "+use)
+			return
+		}
+		c.Next()
+	}
+}
+
+// forbidden aborts the request with a 403, routed through the owning
+// AuthMiddleware/MultiIssuerMiddleware's Unauthorized hook (stashed in the
+// context under UnauthorizedHandlerKey by middlewareImpl) so a caller with a
+// custom Unauthorized gets a consistent response shape for both 401s and
+// these guards' 403s.
+func forbidden(c *gin.Context, message string) {
+	c.Abort()
+
+	if handler, ok := c.Get(UnauthorizedHandlerKey); ok {
+		if fn, ok := handler.(func(*gin.Context, int, string)); ok {
+			fn(c, http.StatusForbidden, message)
+			return
+		}
+	}
+	c.JSON(http.StatusForbidden, AuthError{Code: http.StatusForbidden, Message: message})
+}
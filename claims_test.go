@@ -0,0 +1,134 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractClaims(t *testing.T) {
+	authTime := time.Now().Add(-time.Minute).Unix()
+	claims := jwtgo.MapClaims{
+		"sub":              "user-123",
+		"cognito:username": "jdoe",
+		"email":            "jdoe@example.com",
+		"cognito:groups":   []interface{}{"admins", "editors"},
+		"scope":            "read write",
+		"client_id":        "client-abc",
+		"token_use":        "access",
+		"auth_time":        float64(authTime),
+	}
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(JWTTokenKey, &jwtgo.Token{Claims: claims})
+
+	got, ok := ExtractClaims(c)
+	assert.True(t, ok)
+	assert.Equal(t, "user-123", got.Subject)
+	assert.Equal(t, "jdoe", got.Username)
+	assert.Equal(t, "jdoe@example.com", got.Email)
+	assert.Equal(t, []string{"admins", "editors"}, got.Groups)
+	assert.Equal(t, "read write", got.Scope)
+	assert.Equal(t, []string{"read", "write"}, got.Scopes())
+	assert.Equal(t, "client-abc", got.ClientID)
+	assert.Equal(t, "access", got.TokenUse)
+	assert.Equal(t, authTime, got.AuthTime.Unix())
+	assert.True(t, got.HasScope("read"))
+	assert.False(t, got.HasScope("delete"))
+	assert.True(t, got.HasGroup("admins"))
+	assert.False(t, got.HasGroup("nobody"))
+}
+
+func Test_ExtractClaims_NoValidatedToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	_, ok := ExtractClaims(c)
+	assert.False(t, ok)
+}
+
+// guardTestRouter wires a fake auth step that stashes claims (and optionally
+// an Unauthorized hook) on the context the way AuthMiddleware/
+// MultiIssuerMiddleware do, followed by guard and a handler that only runs
+// if guard called c.Next().
+func guardTestRouter(claims jwtgo.MapClaims, unauthorized func(*gin.Context, int, string), guard gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(JWTTokenKey, &jwtgo.Token{Claims: claims})
+		if unauthorized != nil {
+			c.Set(UnauthorizedHandlerKey, unauthorized)
+		}
+		c.Next()
+	})
+	r.Use(guard)
+	r.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+	return r
+}
+
+func performGet(r *gin.Engine) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func Test_RequireScopes(t *testing.T) {
+	claims := jwtgo.MapClaims{"scope": "read write"}
+
+	ok := performGet(guardTestRouter(claims, nil, RequireScopes("read", "write")))
+	assert.Equal(t, http.StatusOK, ok.Code)
+
+	missing := performGet(guardTestRouter(claims, nil, RequireScopes("delete")))
+	assert.Equal(t, http.StatusForbidden, missing.Code)
+}
+
+func Test_RequireGroups(t *testing.T) {
+	claims := jwtgo.MapClaims{"cognito:groups": []interface{}{"admins"}}
+
+	ok := performGet(guardTestRouter(claims, nil, RequireGroups("admins")))
+	assert.Equal(t, http.StatusOK, ok.Code)
+
+	missing := performGet(guardTestRouter(claims, nil, RequireGroups("superusers")))
+	assert.Equal(t, http.StatusForbidden, missing.Code)
+}
+
+func Test_RequireTokenUse(t *testing.T) {
+	claims := jwtgo.MapClaims{"token_use": "access"}
+
+	ok := performGet(guardTestRouter(claims, nil, RequireTokenUse("access")))
+	assert.Equal(t, http.StatusOK, ok.Code)
+
+	mismatch := performGet(guardTestRouter(claims, nil, RequireTokenUse("id")))
+	assert.Equal(t, http.StatusForbidden, mismatch.Code)
+}
+
+// Test_RequireScopes_UsesOwningMiddlewareUnauthorized is the regression
+// test for routing these guards' 403s through the owning middleware's
+// Unauthorized hook instead of a hardcoded response shape.
+func Test_RequireScopes_UsesOwningMiddlewareUnauthorized(t *testing.T) {
+	claims := jwtgo.MapClaims{"scope": "read"}
+
+	var gotCode int
+	var gotMessage string
+	custom := func(c *gin.Context, code int, message string) {
+		gotCode = code
+		gotMessage = message
+		c.JSON(code, gin.H{"custom": message})
+	}
+
+	resp := performGet(guardTestRouter(claims, custom, RequireScopes("write")))
+
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Equal(t, http.StatusForbidden, gotCode)
+	assert.Contains(t, gotMessage, "write")
+	assert.Contains(t, resp.Body.String(), "custom")
+}
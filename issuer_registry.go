@@ -0,0 +1,265 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt/v4"
+)
+
+// ErrUnknownIssuer is returned when a token's iss claim does not match any
+// issuer configured on an IssuerRegistry and no IssuerResolver is set. When
+// a resolver is set and rejects the issuer, its own error is returned as is.
+var ErrUnknownIssuer = errors.New("unknown issuer")
+
+// IssuerConfig describes one Cognito user pool that a MultiIssuerMiddleware
+// should accept tokens from.
+type IssuerConfig struct {
+	Iss        string
+	Region     string
+	UserPoolID string
+}
+
+// IssuerResolver resolves an issuer that isn't one of an IssuerRegistry's
+// configured IssuerConfigs, e.g. by looking up a tenant's user pool in a
+// database. It is consulted only after a configured-issuer lookup misses.
+type IssuerResolver func(iss string) (*AuthMiddleware, error)
+
+// IssuerRegistry lazily builds and caches one AuthMiddleware (and its JWKS
+// cache) per issuer, so a single gin route can accept tokens from several
+// federated Cognito user pools or other OIDC providers.
+type IssuerRegistry struct {
+	configs  map[string]IssuerConfig
+	resolver IssuerResolver
+	opts     []JWKSCacheOption
+
+	mu          sync.RWMutex
+	middlewares map[string]*AuthMiddleware
+	building    map[string]*sync.Mutex
+}
+
+// NewIssuerRegistry creates a registry for the given issuers. The JWKS for
+// each issuer is fetched lazily, on the first token that names it, rather
+// than at construction time. opts are applied to every issuer's JWKSCache.
+func NewIssuerRegistry(issuers []IssuerConfig, opts ...JWKSCacheOption) *IssuerRegistry {
+	configs := make(map[string]IssuerConfig, len(issuers))
+	for _, cfg := range issuers {
+		configs[cfg.Iss] = cfg
+	}
+
+	return &IssuerRegistry{
+		configs:     configs,
+		opts:        opts,
+		middlewares: make(map[string]*AuthMiddleware),
+		building:    make(map[string]*sync.Mutex),
+	}
+}
+
+// WithIssuerResolver sets a fallback resolver consulted when a token's
+// issuer isn't one of the registry's configured issuers, e.g. to add
+// issuers dynamically from a tenant lookup. It returns the registry so it
+// can be chained onto NewIssuerRegistry.
+func (r *IssuerRegistry) WithIssuerResolver(resolver IssuerResolver) *IssuerRegistry {
+	r.resolver = resolver
+	return r
+}
+
+// Resolve returns the AuthMiddleware responsible for validating tokens from
+// the given issuer, building and caching it on first use. Building a new
+// issuer's middleware (a blocking JWKS fetch, or a caller-supplied
+// IssuerResolver call such as a tenant lookup) is only ever serialized
+// against other Resolve calls for that same issuer, via a per-issuer build
+// lock, so one slow or unreachable issuer cannot stall lookups of issuers
+// already cached.
+//
+// iss is attacker-controlled: MultiIssuerMiddleware reads it from a token's
+// unverified claims before Resolve ever validates a signature, so a caller
+// can name arbitrarily many distinct issuers without presenting a valid
+// token for any of them. A build lock is therefore only ever created for an
+// issuer that is configured or when a resolver is set, and is discarded
+// again if building it fails (including the resolver rejecting it), so
+// garbage issuer strings cannot accumulate unbounded entries.
+func (r *IssuerRegistry) Resolve(iss string) (*AuthMiddleware, error) {
+	r.mu.RLock()
+	mw, ok := r.middlewares[iss]
+	_, known := r.configs[iss]
+	hasResolver := r.resolver != nil
+	r.mu.RUnlock()
+	if ok {
+		return mw, nil
+	}
+	if !known && !hasResolver {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownIssuer, iss)
+	}
+
+	lock := r.buildLock(iss)
+	lock.Lock()
+	defer lock.Unlock()
+
+	r.mu.RLock()
+	mw, ok = r.middlewares[iss]
+	r.mu.RUnlock()
+	if ok {
+		return mw, nil
+	}
+
+	cfg, ok := r.configs[iss]
+	var built *AuthMiddleware
+	var err error
+	if !ok {
+		if built, err = r.resolver(iss); err != nil {
+			r.clearBuildLock(iss)
+			return nil, err
+		}
+		built.MiddlewareInit()
+	} else {
+		if built, err = AuthJWTMiddleware(cfg.Iss, cfg.UserPoolID, cfg.Region, r.opts...); err != nil {
+			r.clearBuildLock(iss)
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	r.middlewares[iss] = built
+	delete(r.building, iss)
+	r.mu.Unlock()
+	return built, nil
+}
+
+// buildLock returns the mutex that serializes building iss's middleware,
+// creating one on first request for that issuer. Callers must only invoke
+// this once iss is known to be configured or resolver-eligible, so that a
+// flood of bogus issuers can't grow this map without bound.
+func (r *IssuerRegistry) buildLock(iss string) *sync.Mutex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lock, ok := r.building[iss]
+	if !ok {
+		lock = &sync.Mutex{}
+		r.building[iss] = lock
+	}
+	return lock
+}
+
+// clearBuildLock discards iss's build lock after a failed build attempt, so
+// an issuer that never successfully resolves (e.g. a resolver rejecting it
+// every time) doesn't leave a permanent entry behind.
+func (r *IssuerRegistry) clearBuildLock(iss string) {
+	r.mu.Lock()
+	delete(r.building, iss)
+	r.mu.Unlock()
+}
+
+// MultiIssuerMiddleware validates tokens against whichever issuer they
+// name, federating several Cognito user pools (or other OIDC providers)
+// behind a single gin middleware. The token is parsed without verification
+// first to read its iss claim, then the matching AuthMiddleware's own
+// parse does the real signature and claims validation.
+type MultiIssuerMiddleware struct {
+	Registry *IssuerRegistry
+
+	// Unauthorized, TokenLookup, AuthScheme and Realm mirror AuthMiddleware's
+	// fields of the same name.
+	Unauthorized func(*gin.Context, int, string)
+	TokenLookup  string
+	AuthScheme   string
+	Realm        string
+}
+
+// NewMultiIssuerMiddleware creates a MultiIssuerMiddleware backed by the
+// given registry.
+func NewMultiIssuerMiddleware(registry *IssuerRegistry) *MultiIssuerMiddleware {
+	return &MultiIssuerMiddleware{Registry: registry}
+}
+
+// MiddlewareFunc implements the Middleware interface.
+func (mw *MultiIssuerMiddleware) MiddlewareFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mw.middlewareImpl(c)
+	}
+}
+
+func (mw *MultiIssuerMiddleware) middlewareImpl(c *gin.Context) {
+	tokenLookup := mw.TokenLookup
+	if tokenLookup == "" {
+		tokenLookup = "header:" + HeaderAuthorization
+	}
+	authScheme := mw.AuthScheme
+	if authScheme == "" {
+		authScheme = "Bearer"
+	}
+
+	tokenStr, err := tokenFromRequest(c, tokenLookup, authScheme)
+	if err != nil {
+		mw.unauthorized(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	iss, err := peekIssuer(tokenStr)
+	if err != nil {
+		mw.unauthorized(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	resolved, err := mw.Registry.Resolve(iss)
+	if err != nil {
+		mw.unauthorized(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	token, err := resolved.parse(tokenStr)
+	if err != nil {
+		mw.unauthorized(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	c.Set(JWTTokenKey, token)
+	c.Set(UnauthorizedHandlerKey, mw.unauthorizedHandler())
+	c.Next()
+}
+
+// unauthorizedHandler returns the Unauthorized hook RequireScopes and
+// friends should use for this request, falling back to the same default
+// response shape as unauthorized itself.
+func (mw *MultiIssuerMiddleware) unauthorizedHandler() func(*gin.Context, int, string) {
+	if mw.Unauthorized != nil {
+		return mw.Unauthorized
+	}
+	return func(c *gin.Context, code int, message string) {
+		c.JSON(code, AuthError{Code: code, Message: message})
+	}
+}
+
+func (mw *MultiIssuerMiddleware) unauthorized(c *gin.Context, code int, message string) {
+	realm := mw.Realm
+	if realm == "" {
+		realm = "gin jwt"
+	}
+	c.Header(HeaderAuthenticate, "JWT realm="+realm)
+	c.Abort()
+
+	if mw.Unauthorized != nil {
+		mw.Unauthorized(c, code, message)
+		return
+	}
+	c.JSON(code, AuthError{Code: code, Message: message})
+}
+
+// peekIssuer extracts the iss claim from a JWT without verifying its
+// signature, so the right issuer's AuthMiddleware (and JWKS) can be looked
+// up before the real validation happens.
+func peekIssuer(tokenStr string) (string, error) {
+	claims := jwtgo.MapClaims{}
+	if _, _, err := new(jwtgo.Parser).ParseUnverified(tokenStr, claims); err != nil {
+		return "", fmt.Errorf("parsing token: %w", err)
+	}
+
+	iss, ok := claims[IssuerFieldName].(string)
+	if !ok || iss == "" {
+		return "", errors.New("token does not contain issuer")
+	}
+	return iss, nil
+}
@@ -0,0 +1,120 @@
+package jwt
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IssuerRegistry_Resolve_CachesPerIssuer(t *testing.T) {
+	var calls int32
+	resolver := func(iss string) (*AuthMiddleware, error) {
+		atomic.AddInt32(&calls, 1)
+		return &AuthMiddleware{Iss: iss}, nil
+	}
+	registry := NewIssuerRegistry(nil).WithIssuerResolver(resolver)
+
+	mw1, err := registry.Resolve("https://issuer-a")
+	assert.NoError(t, err)
+	mw2, err := registry.Resolve("https://issuer-a")
+	assert.NoError(t, err)
+
+	assert.Same(t, mw1, mw2)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func Test_IssuerRegistry_Resolve_UnknownIssuerWithoutResolver(t *testing.T) {
+	registry := NewIssuerRegistry(nil)
+
+	_, err := registry.Resolve("https://unknown")
+	assert.ErrorIs(t, err, ErrUnknownIssuer)
+	assert.Empty(t, registry.building)
+}
+
+// Test_IssuerRegistry_Resolve_RejectedIssuersDontAccumulate is the
+// regression test for an unbounded-memory DoS: peekIssuer reads iss from an
+// unverified token, so an unauthenticated caller can name arbitrarily many
+// garbage issuers. Resolve must not leave a permanent building-lock entry
+// behind for ones the resolver rejects.
+func Test_IssuerRegistry_Resolve_RejectedIssuersDontAccumulate(t *testing.T) {
+	resolver := func(iss string) (*AuthMiddleware, error) {
+		return nil, ErrUnknownIssuer
+	}
+	registry := NewIssuerRegistry(nil).WithIssuerResolver(resolver)
+
+	for i := 0; i < 50; i++ {
+		_, err := registry.Resolve(fmt.Sprintf("https://garbage-%d", i))
+		assert.Error(t, err)
+	}
+
+	assert.Empty(t, registry.building)
+	assert.Empty(t, registry.middlewares)
+}
+
+// Test_IssuerRegistry_Resolve_ConcurrentSameIssuerBuildsOnce covers the
+// other half of the per-issuer build lock: a burst of requests naming the
+// same not-yet-cached issuer must still only build it once.
+func Test_IssuerRegistry_Resolve_ConcurrentSameIssuerBuildsOnce(t *testing.T) {
+	var calls int32
+	resolver := func(iss string) (*AuthMiddleware, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &AuthMiddleware{Iss: iss}, nil
+	}
+	registry := NewIssuerRegistry(nil).WithIssuerResolver(resolver)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := registry.Resolve("https://issuer-a")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+// Test_IssuerRegistry_Resolve_DifferentIssuersDontBlockEachOther is the
+// regression test for the stall a registry-wide lock used to cause: one
+// issuer stuck building (a hung JWKS fetch or a slow tenant lookup) must not
+// stop Resolve from returning for an unrelated issuer.
+func Test_IssuerRegistry_Resolve_DifferentIssuersDontBlockEachOther(t *testing.T) {
+	slow := make(chan struct{})
+	resolver := func(iss string) (*AuthMiddleware, error) {
+		if iss == "https://slow" {
+			<-slow
+		}
+		return &AuthMiddleware{Iss: iss}, nil
+	}
+	registry := NewIssuerRegistry(nil).WithIssuerResolver(resolver)
+
+	slowDone := make(chan struct{})
+	go func() {
+		_, _ = registry.Resolve("https://slow")
+		close(slowDone)
+	}()
+	time.Sleep(10 * time.Millisecond) // let the slow Resolve take its build lock first
+
+	fastErr := make(chan error, 1)
+	go func() {
+		_, err := registry.Resolve("https://fast")
+		fastErr <- err
+	}()
+
+	select {
+	case err := <-fastErr:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("resolving a different issuer was blocked by a slow one still building")
+	}
+
+	close(slow)
+	<-slowDone
+}
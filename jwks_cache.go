@@ -0,0 +1,189 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultJWKSRefreshInterval is how often the JWKS is re-fetched in the
+	// background when the source does not report its own freshness (e.g. an
+	// HTTP Cache-Control max-age).
+	DefaultJWKSRefreshInterval = 1 * time.Hour
+
+	// DefaultJWKSMinRefreshInterval is the minimum time that must pass
+	// between two on-demand refreshes triggered by an unknown kid. This
+	// protects Cognito from a stampede of refreshes when a burst of
+	// requests hits a newly rotated key at the same time.
+	DefaultJWKSMinRefreshInterval = 5 * time.Minute
+)
+
+// JWKSCache fetches a JWKS's keys through a KeySource and keeps them fresh
+// in the background. Callers look up keys by kid; a miss can trigger an
+// out-of-band refresh, rate-limited so that concurrent misses only cause a
+// single call to the source.
+type JWKSCache struct {
+	source KeySource
+
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]JWKKey
+	lastFetch time.Time
+
+	refreshMu sync.Mutex
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// JWKSCacheOption configures a JWKSCache returned by NewJWKSCache or
+// NewJWKSCacheFromSource.
+type JWKSCacheOption func(*JWKSCache)
+
+// WithRefreshInterval sets how often the JWKS is re-fetched in the
+// background. It is overridden when the source reports its own freshness,
+// e.g. an HTTP Cache-Control max-age.
+func WithRefreshInterval(d time.Duration) JWKSCacheOption {
+	return func(c *JWKSCache) {
+		c.refreshInterval = d
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to fetch the JWKS. It only
+// applies to NewJWKSCache's default HTTP source; it has no effect when a
+// custom KeySource is supplied via NewJWKSCacheFromSource.
+func WithHTTPClient(client *http.Client) JWKSCacheOption {
+	return func(c *JWKSCache) {
+		if s, ok := c.source.(*httpKeySource); ok {
+			s.client = client
+		}
+	}
+}
+
+// WithMinRefreshInterval sets the minimum time that must pass between two
+// on-demand refreshes triggered by an unknown kid.
+func WithMinRefreshInterval(d time.Duration) JWKSCacheOption {
+	return func(c *JWKSCache) {
+		c.minRefreshInterval = d
+	}
+}
+
+// NewJWKSCache creates a JWKSCache that fetches from the given JWKS URL
+// over HTTP, performs an initial fetch so the first request can be served
+// immediately, and starts the background refresher.
+func NewJWKSCache(jwkURL string, opts ...JWKSCacheOption) (*JWKSCache, error) {
+	return NewJWKSCacheFromSource(&httpKeySource{
+		url:    jwkURL,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, opts...)
+}
+
+// NewJWKSCacheFromSource creates a JWKSCache backed by an arbitrary
+// KeySource, performs an initial fetch, and starts the background
+// refresher. Use this to pull JWKS material from somewhere other than a
+// plain HTTP GET, e.g. a signed document in S3 or Secrets Manager.
+func NewJWKSCacheFromSource(source KeySource, opts ...JWKSCacheOption) (*JWKSCache, error) {
+	c := &JWKSCache{
+		source:             source,
+		refreshInterval:    DefaultJWKSRefreshInterval,
+		minRefreshInterval: DefaultJWKSMinRefreshInterval,
+		stopCh:             make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if err := c.refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	go c.refreshLoop()
+
+	return c, nil
+}
+
+// Get returns the cached JWK for the given kid, and whether it was found.
+func (c *JWKSCache) Get(kid string) (JWKKey, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+// TriggerRefreshForUnknownKid refreshes the JWKS out of band when
+// verification encounters a kid that isn't cached, which normally means
+// the signing keys rotated between scheduled refreshes. Refreshes are
+// serialized and rate-limited by minRefreshInterval, so a burst of
+// requests hitting the same rotated kid results in a single fetch.
+func (c *JWKSCache) TriggerRefreshForUnknownKid(ctx context.Context) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	c.mu.RLock()
+	sinceLast := time.Since(c.lastFetch)
+	c.mu.RUnlock()
+	if sinceLast < c.minRefreshInterval {
+		return
+	}
+
+	if err := c.refresh(ctx); err != nil {
+		Error.Printf("Failed to refresh JWKS on kid miss: %v", err)
+	}
+}
+
+// Close stops the background refresher. It is safe to call more than once.
+func (c *JWKSCache) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
+}
+
+func (c *JWKSCache) refreshLoop() {
+	timer := time.NewTimer(c.currentInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-timer.C:
+			if err := c.refresh(context.Background()); err != nil {
+				Error.Printf("Failed to refresh JWKS: %v", err)
+			}
+			timer.Reset(c.currentInterval())
+		}
+	}
+}
+
+// currentInterval returns how long to wait before the next background
+// refresh: the source's own freshness hint (e.g. an HTTP Cache-Control
+// max-age) when it reports one, else the configured refreshInterval.
+func (c *JWKSCache) currentInterval() time.Duration {
+	if ma, ok := c.source.(interface{ maxAge() time.Duration }); ok {
+		if d := ma.maxAge(); d > 0 {
+			return d
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.refreshInterval
+}
+
+func (c *JWKSCache) refresh(ctx context.Context) error {
+	keys, err := c.source.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.lastFetch = time.Now()
+	c.mu.Unlock()
+
+	return nil
+}
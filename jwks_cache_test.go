@@ -0,0 +1,84 @@
+package jwt
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingKeySource counts how many times Fetch is called, and stalls
+// briefly so concurrent callers actually overlap.
+type countingKeySource struct {
+	fetches atomic.Int64
+	keys    map[string]JWKKey
+}
+
+func (s *countingKeySource) Fetch(_ context.Context) (map[string]JWKKey, error) {
+	s.fetches.Add(1)
+	time.Sleep(10 * time.Millisecond)
+	return s.keys, nil
+}
+
+func Test_JWKSCache_Get(t *testing.T) {
+	source := &countingKeySource{keys: map[string]JWKKey{"kid-a": {Kid: "kid-a"}}}
+	cache, err := NewJWKSCacheFromSource(source, WithRefreshInterval(time.Hour))
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	key, ok := cache.Get("kid-a")
+	assert.True(t, ok)
+	assert.Equal(t, "kid-a", key.Kid)
+
+	_, ok = cache.Get("unknown")
+	assert.False(t, ok)
+
+	assert.EqualValues(t, 1, source.fetches.Load())
+}
+
+// Test_JWKSCache_ConcurrentKidMissTriggersSingleRefresh covers the stampede
+// protection TriggerRefreshForUnknownKid is meant to provide: a burst of
+// requests hitting the same unknown kid (e.g. right after Cognito rotates
+// its signing keys) must cause at most one extra fetch, not one per request.
+func Test_JWKSCache_ConcurrentKidMissTriggersSingleRefresh(t *testing.T) {
+	source := &countingKeySource{keys: map[string]JWKKey{}}
+	cache, err := NewJWKSCacheFromSource(source, WithRefreshInterval(time.Hour), WithMinRefreshInterval(5*time.Millisecond))
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	assert.EqualValues(t, 1, source.fetches.Load())
+	time.Sleep(10 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.TriggerRefreshForUnknownKid(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 2, source.fetches.Load())
+}
+
+func Test_JWKSCache_TriggerRefreshRespectsMinInterval(t *testing.T) {
+	source := &countingKeySource{keys: map[string]JWKKey{}}
+	cache, err := NewJWKSCacheFromSource(source, WithRefreshInterval(time.Hour), WithMinRefreshInterval(5*time.Millisecond))
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	assert.EqualValues(t, 1, source.fetches.Load())
+	time.Sleep(10 * time.Millisecond)
+
+	cache.TriggerRefreshForUnknownKid(context.Background())
+	assert.EqualValues(t, 2, source.fetches.Load())
+
+	// Second miss arrives well within minRefreshInterval and should not
+	// cause another fetch.
+	cache.TriggerRefreshForUnknownKid(context.Background())
+	assert.EqualValues(t, 2, source.fetches.Load())
+}
@@ -0,0 +1,69 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+)
+
+// AuthJWTMiddlewareFromJWKS builds an AuthMiddleware from a JWKS document
+// read once from jwks, performing no HTTP request at all. Use this for
+// air-gapped deployments, container startup in networks that can't reach
+// Cognito, or pinning keys fetched out of band (e.g. from S3 or Secrets
+// Manager onto local disk — see FileKeySource for a version that re-reads
+// the file on every background refresh).
+func AuthJWTMiddlewareFromJWKS(iss, userPoolID, region string, jwks io.Reader) (*AuthMiddleware, error) {
+	keys, err := decodeJWKS(jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	return AuthJWTMiddlewareFromKeySource(iss, userPoolID, region, StaticKeySource{Keys: keys})
+}
+
+// AuthJWTMiddlewareFromKeys builds an AuthMiddleware from a fixed set of
+// RSA public keys keyed by kid, with no network or filesystem access at
+// all — the simplest way to exercise AuthMiddleware in unit tests.
+func AuthJWTMiddlewareFromKeys(iss string, keys map[string]*rsa.PublicKey) (*AuthMiddleware, error) {
+	jwkKeys := make(map[string]JWKKey, len(keys))
+	for kid, pub := range keys {
+		jwkKeys[kid] = jwkKeyFromRSAPublicKey(kid, pub)
+	}
+
+	return AuthJWTMiddlewareFromKeySource(iss, "", "", StaticKeySource{Keys: jwkKeys})
+}
+
+// AuthJWTMiddlewareFromKeySource builds an AuthMiddleware whose JWKSCache
+// fetches through source instead of the default HTTP GET against
+// Cognito's JWKS endpoint, e.g. to pull a signed JWKS from S3 or Secrets
+// Manager, or to serve it from a local file via FileKeySource.
+func AuthJWTMiddlewareFromKeySource(iss, userPoolID, region string, source KeySource, opts ...JWKSCacheOption) (*AuthMiddleware, error) {
+	jwks, err := NewJWKSCacheFromSource(source, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAuthMiddlewareWithCache(iss, userPoolID, region, jwks), nil
+}
+
+// jwkKeyFromRSAPublicKey encodes pub the way Cognito's JWKS does, the
+// inverse of convertKey, so a caller-supplied rsa.PublicKey can be cached
+// and looked up like any other JWKKey.
+func jwkKeyFromRSAPublicKey(kid string, pub *rsa.PublicKey) JWKKey {
+	eBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(eBytes, uint32(pub.E))
+	i := 0
+	for i < len(eBytes)-1 && eBytes[i] == 0 {
+		i++
+	}
+
+	return JWKKey{
+		Kid: kid,
+		Kty: "RSA",
+		Alg: "RS256",
+		Use: "sig",
+		E:   base64.RawURLEncoding.EncodeToString(eBytes[i:]),
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+	}
+}
@@ -0,0 +1,40 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AuthJWTMiddlewareFromKeys(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	mw, err := AuthJWTMiddlewareFromKeys("https://example.test/issuer", map[string]*rsa.PublicKey{
+		"test-kid": &priv.PublicKey,
+	})
+	assert.NoError(t, err)
+
+	key, ok := mw.lookupKey("test-kid")
+	assert.True(t, ok)
+
+	roundTripped := convertKey(key.E, key.N)
+	assert.Equal(t, priv.PublicKey.E, roundTripped.E)
+	assert.Equal(t, 0, priv.PublicKey.N.Cmp(roundTripped.N))
+}
+
+func Test_AuthJWTMiddlewareFromJWKS(t *testing.T) {
+	jwks := `{"keys":[{"alg":"RS256","e":"AQAB","kid":"from-jwks","kty":"RSA","n":"AQAB","use":"sig"}]}`
+
+	mw, err := AuthJWTMiddlewareFromJWKS("https://example.test/issuer", "pool", "region", strings.NewReader(jwks))
+	assert.NoError(t, err)
+
+	_, ok := mw.lookupKey("from-jwks")
+	assert.True(t, ok)
+
+	_, ok = mw.lookupKey("unknown-kid")
+	assert.False(t, ok)
+}
@@ -0,0 +1,110 @@
+package jwt
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// COOKIE token lookup source: a cookie name.
+	COOKIE = "cookie"
+
+	// QUERY token lookup source: a URL query parameter.
+	QUERY = "query"
+
+	// FORM token lookup source: a form field.
+	FORM = "form"
+)
+
+// tokenFromRequest extracts a token string from c by following tokenLookup,
+// a comma-separated list of "source:key" pairs evaluated in order, e.g.
+// "header:Authorization,cookie:jwt,query:access_token,form:token". The
+// first source that yields a non-empty token wins; a source whose key is
+// simply absent (no such header/cookie/query/form field) is skipped rather
+// than treated as an error. authScheme (e.g. "Bearer") is stripped from the
+// header source only, case-insensitively; a header that doesn't carry that
+// exact scheme is rejected with ErrInvalidAuthHeader rather than silently
+// falling through to the next source.
+func tokenFromRequest(c *gin.Context, tokenLookup, authScheme string) (string, error) {
+	lastErr := ErrAuthHeaderEmpty
+
+	for _, lookup := range strings.Split(tokenLookup, ",") {
+		source, key, found := strings.Cut(strings.TrimSpace(lookup), ":")
+		if !found {
+			continue
+		}
+
+		var value string
+		var err error
+		switch source {
+		case HEADER:
+			value, err = tokenFromHeader(c, key, authScheme)
+		case COOKIE:
+			value, err = tokenFromCookie(c, key)
+		case QUERY:
+			value, err = tokenFromQuery(c, key)
+		case FORM:
+			value, err = tokenFromForm(c, key)
+		default:
+			continue
+		}
+
+		if err != nil {
+			if errors.Is(err, ErrInvalidAuthHeader) {
+				return "", err
+			}
+			lastErr = err
+			continue
+		}
+		if value != "" {
+			return value, nil
+		}
+	}
+
+	return "", lastErr
+}
+
+// tokenFromHeader reads key off the request header and, when authScheme is
+// set, requires and strips a "<authScheme> " prefix (case-insensitive).
+func tokenFromHeader(c *gin.Context, key, authScheme string) (string, error) {
+	authHeader := c.Request.Header.Get(key)
+	if authHeader == "" {
+		return "", ErrAuthHeaderEmpty
+	}
+
+	if authScheme == "" {
+		return authHeader, nil
+	}
+
+	scheme, token, found := strings.Cut(authHeader, " ")
+	if !found || !strings.EqualFold(scheme, authScheme) {
+		return "", ErrInvalidAuthHeader
+	}
+	return strings.TrimSpace(token), nil
+}
+
+func tokenFromCookie(c *gin.Context, key string) (string, error) {
+	token, err := c.Cookie(key)
+	if err != nil || token == "" {
+		return "", ErrAuthHeaderEmpty
+	}
+	return token, nil
+}
+
+func tokenFromQuery(c *gin.Context, key string) (string, error) {
+	token := c.Query(key)
+	if token == "" {
+		return "", ErrAuthHeaderEmpty
+	}
+	return token, nil
+}
+
+func tokenFromForm(c *gin.Context, key string) (string, error) {
+	token := c.PostForm(key)
+	if token == "" {
+		return "", ErrAuthHeaderEmpty
+	}
+	return token, nil
+}
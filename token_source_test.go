@@ -0,0 +1,108 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestContext(method, target string, body string, headers map[string]string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+
+	var reqBody *strings.Reader
+	if body != "" {
+		reqBody = strings.NewReader(body)
+	} else {
+		reqBody = strings.NewReader("")
+	}
+
+	req := httptest.NewRequest(method, target, reqBody)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	return c
+}
+
+func Test_tokenFromRequest_header(t *testing.T) {
+	c := newTestContext(http.MethodGet, "/", "", map[string]string{
+		HeaderAuthorization: "Bearer sometoken",
+	})
+
+	token, err := tokenFromRequest(c, "header:"+HeaderAuthorization, "Bearer")
+	assert.NoError(t, err)
+	assert.Equal(t, "sometoken", token)
+}
+
+func Test_tokenFromRequest_headerSchemeCaseInsensitive(t *testing.T) {
+	c := newTestContext(http.MethodGet, "/", "", map[string]string{
+		HeaderAuthorization: "bearer sometoken",
+	})
+
+	token, err := tokenFromRequest(c, "header:"+HeaderAuthorization, "Bearer")
+	assert.NoError(t, err)
+	assert.Equal(t, "sometoken", token)
+}
+
+func Test_tokenFromRequest_headerWrongScheme(t *testing.T) {
+	c := newTestContext(http.MethodGet, "/", "", map[string]string{
+		HeaderAuthorization: "Basic sometoken",
+	})
+
+	_, err := tokenFromRequest(c, "header:"+HeaderAuthorization, "Bearer")
+	assert.ErrorIs(t, err, ErrInvalidAuthHeader)
+}
+
+func Test_tokenFromRequest_cookie(t *testing.T) {
+	c := newTestContext(http.MethodGet, "/", "", map[string]string{
+		"Cookie": "jwt=cookietoken",
+	})
+
+	token, err := tokenFromRequest(c, "cookie:jwt", "Bearer")
+	assert.NoError(t, err)
+	assert.Equal(t, "cookietoken", token)
+}
+
+func Test_tokenFromRequest_query(t *testing.T) {
+	c := newTestContext(http.MethodGet, "/?access_token=querytoken", "", nil)
+
+	token, err := tokenFromRequest(c, "query:access_token", "Bearer")
+	assert.NoError(t, err)
+	assert.Equal(t, "querytoken", token)
+}
+
+func Test_tokenFromRequest_form(t *testing.T) {
+	c := newTestContext(http.MethodPost, "/", url.Values{"token": {"formtoken"}}.Encode(), nil)
+
+	token, err := tokenFromRequest(c, "form:token", "Bearer")
+	assert.NoError(t, err)
+	assert.Equal(t, "formtoken", token)
+}
+
+func Test_tokenFromRequest_fallbackOrdering(t *testing.T) {
+	c := newTestContext(http.MethodGet, "/?access_token=querytoken", "", map[string]string{
+		"Cookie": "jwt=cookietoken",
+	})
+
+	token, err := tokenFromRequest(c, "header:"+HeaderAuthorization+",cookie:jwt,query:access_token", "Bearer")
+	assert.NoError(t, err)
+	assert.Equal(t, "cookietoken", token, "missing header source should fall through to cookie before query")
+}
+
+func Test_tokenFromRequest_noSourceMatches(t *testing.T) {
+	c := newTestContext(http.MethodGet, "/", "", nil)
+
+	_, err := tokenFromRequest(c, "header:"+HeaderAuthorization+",cookie:jwt", "Bearer")
+	assert.ErrorIs(t, err, ErrAuthHeaderEmpty)
+}